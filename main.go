@@ -6,17 +6,22 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/rand"
+	"crypto/subtle"
 	"embed"
+	"encoding/base64"
 	"errors"
 	"flag"
 	"fmt"
 	"html/template"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
@@ -27,15 +32,27 @@ import (
 	"github.com/gdamore/tcell/v2"
 	"github.com/gdamore/tcell/v2/views"
 
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/pkg/browser"
 	bf "github.com/russross/blackfriday/v2"
 )
 
+const defaultCSP = `default-src 'self'; style-src 'nonce-%[1]s'; script-src 'nonce-%[1]s'`
+
 var (
-	bind         string
-	assets       string
-	stylesheet   string
-	presentation string
-	output       string
+	bind          string
+	assets        string
+	stylesheet    string
+	highlightName string
+	legacyRefresh bool
+	csp           string
+	open          bool
+	controlToken  string
+	presentation  string
+	output        string
 
 	//go:embed partials/*.html styles/*.css
 	embedded  embed.FS
@@ -51,8 +68,19 @@ func init() {
 	flag.StringVar(&bind, "bind", "localhost:8080", "address and port to bind")
 	flag.StringVar(&stylesheet, "style", "builtin:none",
 		"path to extra stylesheet, or a builtin")
+	flag.StringVar(&highlightName, "highlight-style", "",
+		"chroma style for syntax-highlighting code blocks, e.g. builtin:monokai (default off)")
+	flag.BoolVar(&legacyRefresh, "legacy-refresh", false,
+		"fall back to the pre-SSE chunked-response/Refresh hack, for clients that block SSE")
+	flag.StringVar(&csp, "csp", defaultCSP,
+		`Content-Security-Policy: "off" disables it, "unsafe-inline" permits inline style/script`+
+			` without a nonce, anything else is sent verbatim with %[1]s substituted for the nonce`)
 	flag.StringVar(&assets, "asset-dir", ".",
 		"path to dir with images, fonts, etc")
+	flag.BoolVar(&open, "open", false,
+		"open the presenter's default browser at the bind address once the server is listening")
+	flag.StringVar(&controlToken, "control-token", "",
+		"token required by /control/*, /remote and /presenter; a random one is generated and printed if unset")
 	flag.Parse()
 
 	switch flag.NArg() {
@@ -71,14 +99,17 @@ func init() {
 }
 
 type State struct {
-	Current    int // 1-indexed
-	Total      int
-	Generation int
-	Title      string
-	Slides     []template.HTML
-	SlidesRaw  []string
-	UserStyle  template.CSS
-	M          *sync.RWMutex
+	Current       int // 1-indexed
+	Total         int
+	Generation    int
+	Title         string
+	Slides        []template.HTML
+	SlidesRaw     []string
+	Notes         []string
+	UserStyle     template.CSS
+	HighlightCSS  template.CSS
+	LegacyRefresh bool
+	M             *sync.RWMutex
 }
 
 func (s *State) GotoSlide(slide int) {
@@ -94,8 +125,8 @@ func (s *State) GotoSlide(slide int) {
 	}
 }
 
-func (s *State) Reload(presentation, stylesheet string) error {
-	title, slides, slidesRaw, err := loadSlides(presentation)
+func (s *State) Reload(presentation, stylesheet, highlightName string) error {
+	deck, err := loadSlides(presentation, highlightName)
 	if err != nil {
 		return err
 	}
@@ -109,14 +140,16 @@ func (s *State) Reload(presentation, stylesheet string) error {
 	defer s.M.Unlock()
 
 	s.Generation++
-	s.Total = len(slides)
+	s.Total = len(deck.Slides)
 	if s.Current > s.Total {
 		s.Current = s.Total
 	}
-	s.Title = title
-	s.Slides = slides
-	s.SlidesRaw = slidesRaw
+	s.Title = deck.Title
+	s.Slides = deck.Slides
+	s.SlidesRaw = deck.SlidesRaw
+	s.Notes = deck.Notes
 	s.UserStyle = userstyle
+	s.HighlightCSS = deck.HighlightCSS
 
 	return nil
 }
@@ -136,15 +169,164 @@ func loadUserStyle(stylesheet string) (template.CSS, error) {
 	return template.CSS(style), err
 }
 
-func loadSlides(file string) (string, []template.HTML, []string, error) {
+// nonce returns a fresh random, base64-encoded CSP nonce.
+func nonce() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf), nil
+}
+
+// randomToken returns a random value safe to embed directly in a URL query
+// string, unlike nonce() (whose alphabet includes '+' and '/').
+func randomToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// cspPolicy renders the -csp flag into a Content-Security-Policy header
+// value for the given per-response nonce. ok is false if CSP is disabled.
+func cspPolicy(nonce string) (policy string, ok bool) {
+	switch csp {
+	case "off":
+		return "", false
+	case "unsafe-inline":
+		return "default-src 'self'; style-src 'unsafe-inline'; script-src 'unsafe-inline'", true
+	default:
+		// csp is user-supplied (and may contain its own literal '%'s, e.g.
+		// in a report-uri), so substitute the nonce placeholder directly
+		// instead of treating csp as a fmt format string.
+		return strings.ReplaceAll(csp, "%[1]s", nonce), true
+	}
+}
+
+// pageData is what main.html is rendered with: the shared presentation
+// State, plus the nonce minted for this one response.
+type pageData struct {
+	*State
+	Nonce string
+}
+
+// loadHighlightStyle resolves the -highlight-style flag to a chroma style.
+// An empty name disables highlighting entirely (the zero value, nil).
+func loadHighlightStyle(name string) (*chroma.Style, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	name = strings.TrimPrefix(name, "builtin:")
+	style, ok := styles.Registry[name]
+	if !ok {
+		return nil, fmt.Errorf(`tried to load builtin highlight style "%s" that does not exist`, name)
+	}
+	return style, nil
+}
+
+// chromaClassPrefix namespaces the CSS classes chroma emits so they can't
+// collide with a slide's own `.classname` section macro or a user-supplied
+// `-style` stylesheet (chroma's unprefixed output includes bare top-level
+// classes like `.bg`, which are exactly the kind of name a slide author is
+// likely to pick).
+const chromaClassPrefix = "chroma-"
+
+// newChromaFormatter returns a class-based chroma formatter. newHighlightRenderer
+// and highlightCSS must both build their formatter through this function so the
+// class names chroma writes into rendered markup match the selectors in the
+// stylesheet highlightCSS emits.
+func newChromaFormatter() *chromahtml.Formatter {
+	return chromahtml.New(chromahtml.WithClasses(true), chromahtml.ClassPrefix(chromaClassPrefix))
+}
+
+// highlightRenderer wraps blackfriday's stock HTMLRenderer, intercepting
+// fenced code blocks and tokenizing/formatting them with chroma instead of
+// emitting plain <pre><code>.
+type highlightRenderer struct {
+	*bf.HTMLRenderer
+	style     *chroma.Style
+	formatter *chromahtml.Formatter
+}
+
+func newHighlightRenderer(params bf.HTMLRendererParameters, style *chroma.Style) *highlightRenderer {
+	return &highlightRenderer{
+		HTMLRenderer: bf.NewHTMLRenderer(params),
+		style:        style,
+		formatter:    newChromaFormatter(),
+	}
+}
+
+func (r *highlightRenderer) RenderNode(w io.Writer, node *bf.Node, entering bool) bf.WalkStatus {
+	if node.Type != bf.CodeBlock {
+		return r.HTMLRenderer.RenderNode(w, node, entering)
+	}
+
+	lexer := lexers.Fallback
+	if lang := strings.Fields(string(node.CodeBlockData.Info)); len(lang) > 0 {
+		if l := lexers.Get(lang[0]); l != nil {
+			lexer = l
+		}
+	}
+
+	iterator, err := lexer.Tokenise(nil, string(node.Literal))
+	if err != nil {
+		return r.HTMLRenderer.RenderNode(w, node, entering)
+	}
+	if err := r.formatter.Format(w, r.style, iterator); err != nil {
+		return r.HTMLRenderer.RenderNode(w, node, entering)
+	}
+	return bf.GoToNext
+}
+
+// highlightCSS renders the CSS classes chroma's formatter relies on, so
+// they only need to be emitted once per page instead of once per block.
+func highlightCSS(style *chroma.Style) template.CSS {
+	var buf bytes.Buffer
+	newChromaFormatter().WriteCSS(&buf, style)
+	return template.CSS(buf.String())
+}
+
+// slideDeck is the parsed result of loadSlides.
+type slideDeck struct {
+	Title        string
+	Slides       []template.HTML
+	SlidesRaw    []string
+	Notes        []string
+	HighlightCSS template.CSS
+}
+
+var (
+	notesFence = regexp.MustCompile(`(?s)<!--\s*notes\s*\n(.*?)-->\n?`)
+	notesMark  = regexp.MustCompile(`(?ms)^\?\?\?\s*\n`)
+)
+
+// extractNotes pulls speaker notes out of a slide's markdown, supporting
+// either an HTML-comment fence (`<!-- notes ... -->`) or a `???` line
+// (remark.js-style) marking everything after it as notes. It returns the
+// slide with the notes removed, and the notes themselves (empty if none).
+func extractNotes(slide []byte) ([]byte, string) {
+	if loc := notesFence.FindSubmatchIndex(slide); loc != nil {
+		notes := strings.TrimSpace(string(slide[loc[2]:loc[3]]))
+		rest := append(append([]byte{}, slide[:loc[0]]...), slide[loc[1]:]...)
+		return bytes.TrimSpace(rest), notes
+	}
+	if loc := notesMark.FindIndex(slide); loc != nil {
+		notes := strings.TrimSpace(string(slide[loc[1]:]))
+		return bytes.TrimSpace(slide[:loc[0]]), notes
+	}
+	return slide, ""
+}
+
+func loadSlides(file, highlightName string) (slideDeck, error) {
 	if !strings.HasSuffix(file, ".md") {
-		return "", []template.HTML{}, []string{},
-			errors.New(file + " doesn't end in '.md'; not markdown?")
+		return slideDeck{}, errors.New(file + " doesn't end in '.md'; not markdown?")
 	}
 
 	content, err := os.ReadFile(file)
 	if err != nil {
-		return "", []template.HTML{}, []string{}, err
+		return slideDeck{}, err
 	}
 
 	// drops trailing blank lines/slides
@@ -156,16 +338,28 @@ func loadSlides(file string) (string, []template.HTML, []string, error) {
 	imageCaptionBefore := regexp.MustCompile(`<p>(.+)\n(<img[^<>]+/>)</p>`)
 	imageCaptionAfter := regexp.MustCompile(`<p>(<img[^<>]+/>)\n(.+)</p>`)
 
-	// FIXME custom blackfriday HTMLRenderer seems like a better solution
-	title := "websent"
-	slidesHTML := []template.HTML{}
-	slidesMarkdown := []string{}
+	deck := slideDeck{Title: "websent"}
 	class := regexp.MustCompile(`^\.(.)+\n`)
-	bfRenderer := bf.WithRenderer(bf.NewHTMLRenderer(bf.HTMLRendererParameters{
+
+	hlParams := bf.HTMLRendererParameters{
 		Flags: bf.CommonHTMLFlags | bf.HrefTargetBlank | bf.NoreferrerLinks,
-	}))
+	}
+	var renderOpt bf.Option
+	style, err := loadHighlightStyle(highlightName)
+	if err != nil {
+		return slideDeck{}, err
+	}
+	if style != nil {
+		renderOpt = bf.WithRenderer(newHighlightRenderer(hlParams, style))
+		deck.HighlightCSS = highlightCSS(style)
+	} else {
+		renderOpt = bf.WithRenderer(bf.NewHTMLRenderer(hlParams))
+	}
+
 	for idx, slide := range bytes.Split(content, []byte("\n\n\n")) {
 
+		slide, notes := extractNotes(slide)
+
 		macro := class.Find(slide)
 		prefix := "<section id='s" + strconv.Itoa(idx+1) + "'"
 		if len(macro) > 0 {
@@ -175,7 +369,7 @@ func loadSlides(file string) (string, []template.HTML, []string, error) {
 		prefix += ">\n"
 		suffix := "</section>\n"
 
-		text := string(bf.Run(slide, bf.WithExtensions(bf.CommonExtensions), bfRenderer))
+		text := string(bf.Run(slide, bf.WithExtensions(bf.CommonExtensions), renderOpt))
 
 		// apply hacky fix-ups
 		text = imageSingle.ReplaceAllString(text, "$1")
@@ -183,20 +377,21 @@ func loadSlides(file string) (string, []template.HTML, []string, error) {
 		text = imageCaptionAfter.ReplaceAllString(text, "$1\n<p>$2</p>")
 		text = imageMulti.ReplaceAllString(text, "$x")
 
-		slidesHTML = append(slidesHTML, template.HTML(prefix+text+suffix))
-		slidesMarkdown = append(slidesMarkdown, string(slide)+"\n")
+		deck.Slides = append(deck.Slides, template.HTML(prefix+text+suffix))
+		deck.SlidesRaw = append(deck.SlidesRaw, string(slide)+"\n")
+		deck.Notes = append(deck.Notes, notes)
 	}
 
-	if len(slidesMarkdown) < 1 {
-		return title, slidesHTML, slidesMarkdown, errors.New("tried to load a presentation without slides")
+	if len(deck.SlidesRaw) < 1 {
+		return deck, errors.New("tried to load a presentation without slides")
 	}
 
 	if bytes.HasPrefix(content, []byte("# ")) {
 		// string containing everything after "# " in first line
-		title = string(bytes.SplitN(content, []byte("\n"), 2)[0][2:])
+		deck.Title = string(bytes.SplitN(content, []byte("\n"), 2)[0][2:])
 	}
 
-	return title, slidesHTML, slidesMarkdown, nil
+	return deck, nil
 }
 
 func (s *State) EventStream(ctx context.Context, cond *sync.Cond) <-chan interface{} {
@@ -248,23 +443,28 @@ type SlideHandler struct {
 	connected *int32
 }
 
-func NewSlideHandler(ctx context.Context, state *State, cond *sync.Cond) SlideHandler {
+func NewSlideHandler(ctx context.Context, state *State, cond *sync.Cond, connected *int32) SlideHandler {
 	return SlideHandler{
 		ctx:       ctx,
 		state:     state,
 		cond:      cond,
-		connected: new(int32),
+		connected: connected,
 	}
 }
 
 func (h SlideHandler) Dump(w io.Writer) error {
+	n, err := nonce()
+	if err != nil {
+		return err
+	}
+
 	h.state.M.RLock()
-	if err := templates.ExecuteTemplate(w, "main.html", h.state); err != nil {
+	if err := templates.ExecuteTemplate(w, "main.html", pageData{State: h.state, Nonce: n}); err != nil {
 		return err
 	}
 	h.state.M.RUnlock()
 	trailer, _ := embedded.ReadFile("partials/trailer.html")
-	_, err := w.Write(trailer)
+	_, err = w.Write(trailer)
 	return err
 }
 
@@ -275,6 +475,47 @@ func (h SlideHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if legacyRefresh {
+		h.serveLegacy(w, r)
+		return
+	}
+
+	n, err := nonce()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if policy, ok := cspPolicy(n); ok {
+		w.Header().Set("Content-Security-Policy", policy)
+	}
+
+	// Audience clients pick up live updates over /events (see EventsHandler);
+	// this is a plain, one-shot response identical to the Dump output.
+	h.state.M.RLock()
+	err = templates.ExecuteTemplate(w, "main.html", pageData{State: h.state, Nonce: n})
+	h.state.M.RUnlock()
+	if err != nil {
+		return
+	}
+	trailer, _ := embedded.ReadFile("partials/trailer.html")
+	w.Write(trailer)
+}
+
+// serveLegacy implements the pre-SSE behaviour: the response is kept open
+// forever, slide changes are pushed as inline <script> fragments into the
+// still-open document, and a Refresh header forces a reload if the
+// connection is ever dropped and re-established. It only exists for
+// clients behind proxies that buffer or otherwise break SSE.
+func (h SlideHandler) serveLegacy(w http.ResponseWriter, r *http.Request) {
+	n, err := nonce()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if policy, ok := cspPolicy(n); ok {
+		w.Header().Set("Content-Security-Policy", policy)
+	}
+
 	// As long as we're not shutting down, issue a refresh directive.
 	// This allows hot-reloading the page, but forces us to serve a
 	// final reload of the presentation after we've initiated shutdown.
@@ -293,7 +534,7 @@ func (h SlideHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// send presentation content
 	h.state.M.RLock()
-	if err := templates.ExecuteTemplate(w, "main.html", h.state); err != nil {
+	if err := templates.ExecuteTemplate(w, "main.html", pageData{State: h.state, Nonce: n}); err != nil {
 		h.state.M.RUnlock()
 		return
 	}
@@ -324,7 +565,10 @@ func (h SlideHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			switch e := e.(type) {
 			case int:
 				templates.ExecuteTemplate(w, "slidechange.html",
-					struct{ Current int }{Current: e})
+					struct {
+						Current int
+						Nonce   string
+					}{Current: e, Nonce: n})
 				if f, ok := w.(http.Flusher); ok {
 					f.Flush()
 				}
@@ -339,6 +583,221 @@ func (h SlideHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// EventsHandler serves /events: a long-lived Server-Sent Events stream that
+// replaces the legacy chunked-response hack. It emits "goto" events with the
+// new 1-indexed slide number, a "reload" event when the presentation should
+// be reloaded (or the server is shutting down), and periodic "heartbeat"
+// events so intermediate proxies don't time the connection out.
+type EventsHandler struct {
+	ctx       context.Context
+	state     *State
+	cond      *sync.Cond
+	connected *int32
+}
+
+func NewEventsHandler(ctx context.Context, state *State, cond *sync.Cond, connected *int32) EventsHandler {
+	return EventsHandler{
+		ctx:       ctx,
+		state:     state,
+		cond:      cond,
+		connected: connected,
+	}
+}
+
+func (h EventsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	atomic.AddInt32(h.connected, 1)
+	defer atomic.AddInt32(h.connected, -1)
+
+	streamctx, cancel := context.WithCancel(h.ctx)
+	defer cancel()
+	events := h.state.EventStream(streamctx, h.cond)
+
+	for {
+		select {
+		case <-h.ctx.Done():
+			fmt.Fprint(w, "event: reload\ndata:\n\n")
+			flusher.Flush()
+			return
+		case <-r.Context().Done():
+			return
+		case <-time.After(30 * time.Second):
+			fmt.Fprint(w, "event: heartbeat\ndata:\n\n")
+			flusher.Flush()
+		case e, more := <-events:
+			if !more {
+				return
+			}
+
+			switch e := e.(type) {
+			case int:
+				fmt.Fprintf(w, "event: goto\ndata: %d\n\n", e)
+				flusher.Flush()
+			case string:
+				switch e {
+				case "refresh":
+					fmt.Fprint(w, "event: reload\ndata:\n\n")
+					flusher.Flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// presenterData is what presenter.html is rendered with: the shared
+// pageData plus the presenter-only fields that aren't part of State
+// because they're derived, not loaded.
+type presenterData struct {
+	pageData
+	CurrentSlide template.HTML
+	NextSlide    template.HTML
+	Notes        string
+	// StartUnix is when the presentation began, as seconds since the Unix
+	// epoch. The clock itself ticks client-side off this (see
+	// partials/presenter.html) rather than being computed once here, since
+	// the page is only reloaded on slide changes and would otherwise show
+	// a stale elapsed time for as long as the presenter lingers on a slide.
+	StartUnix int64
+}
+
+// PresenterHandler serves /presenter: the current slide, a preview of the
+// next one, speaker notes and an elapsed-time clock. It shares State with
+// SlideHandler and picks up live slide changes over /events like the
+// audience view does (see partials/presenter.html's EventSource use),
+// except that the page reloads on each change.
+type PresenterHandler struct {
+	state *State
+	start time.Time
+}
+
+func NewPresenterHandler(state *State, start time.Time) PresenterHandler {
+	return PresenterHandler{state: state, start: start}
+}
+
+func (h PresenterHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/presenter" {
+		http.NotFound(w, r)
+		return
+	}
+
+	n, err := nonce()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if policy, ok := cspPolicy(n); ok {
+		w.Header().Set("Content-Security-Policy", policy)
+	}
+
+	h.state.M.RLock()
+	defer h.state.M.RUnlock()
+
+	data := presenterData{
+		pageData:  pageData{State: h.state, Nonce: n},
+		StartUnix: h.start.Unix(),
+	}
+	if h.state.Current-1 < len(h.state.Notes) {
+		data.Notes = h.state.Notes[h.state.Current-1]
+	}
+	if h.state.Current-1 < len(h.state.Slides) {
+		data.CurrentSlide = h.state.Slides[h.state.Current-1]
+	}
+	if h.state.Current < len(h.state.Slides) {
+		data.NextSlide = h.state.Slides[h.state.Current]
+	}
+
+	templates.ExecuteTemplate(w, "presenter.html", data)
+}
+
+// requireControlToken protects /control/*, /remote and /presenter with the one-shot
+// token printed at startup (or set via -control-token), checked as a
+// "token" query parameter so plain <form>/fetch() calls from /remote work
+// without needing to set a header.
+func requireControlToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(controlToken)) != 1 {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ControlHandler serves POST /control/{next,prev,goto/N,reload}, driving
+// the same State/cond plumbing the TUI uses so every connected audience
+// client updates via /events. This is what lets a phone or second laptop
+// stand in for the presenter's keyboard.
+type ControlHandler struct {
+	state *State
+	cond  *sync.Cond
+}
+
+func NewControlHandler(state *State, cond *sync.Cond) ControlHandler {
+	return ControlHandler{state: state, cond: cond}
+}
+
+func (h ControlHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	action := strings.TrimPrefix(r.URL.Path, "/control/")
+	switch {
+	case action == "next":
+		h.state.GotoSlide(h.state.Current + 1)
+	case action == "prev":
+		h.state.GotoSlide(h.state.Current - 1)
+	case action == "reload":
+		if err := h.state.Reload(presentation, stylesheet, highlightName); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	case strings.HasPrefix(action, "goto/"):
+		slide, err := strconv.Atoi(strings.TrimPrefix(action, "goto/"))
+		if err != nil {
+			http.Error(w, "bad slide number", http.StatusBadRequest)
+			return
+		}
+		h.state.GotoSlide(slide)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	h.cond.Broadcast()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoteHandler serves /remote: a phone-sized page with big Prev/Next
+// buttons that POST to ControlHandler, carrying the control token along.
+type RemoteHandler struct{}
+
+func (RemoteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n, err := nonce()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if policy, ok := cspPolicy(n); ok {
+		w.Header().Set("Content-Security-Policy", policy)
+	}
+
+	data := struct{ Nonce, Token string }{Nonce: n, Token: r.URL.Query().Get("token")}
+	templates.ExecuteTemplate(w, "remote.html", data)
+}
+
 type Event int16
 
 const (
@@ -434,6 +893,9 @@ func tui(state *State, cond *sync.Cond, connected *int32, dropping *int32, shutd
 		window[idx] = w
 	}
 
+	notes := views.NewText()
+	slides.AddWidget(notes, 0.1)
+
 	status := views.NewTextBar()
 	status.SetLeft(" http://"+bind, tcell.StyleDefault)
 	status.SetRight("j|next k|prev r|eload q|uit ", tcell.StyleDefault)
@@ -465,6 +927,13 @@ func tui(state *State, cond *sync.Cond, connected *int32, dropping *int32, shutd
 			content := strings.Join(strings.Split(r, "\n"), indent)
 			window[idx].SetText(prefix + content)
 		}
+
+		var noteText string
+		if state.Current-1 < len(state.Notes) {
+			noteText = state.Notes[state.Current-1]
+		}
+		notes.SetText(strings.Join(strings.Split(noteText, "\n"), "\n         "))
+
 		slides.Draw()
 	}
 
@@ -495,7 +964,7 @@ func tui(state *State, cond *sync.Cond, connected *int32, dropping *int32, shutd
 			cond.Broadcast()
 			refreshSlide()
 		case Reload:
-			err := state.Reload(presentation, stylesheet)
+			err := state.Reload(presentation, stylesheet, highlightName)
 			if err != nil {
 				log.Println(err)
 				continue
@@ -516,13 +985,39 @@ func tui(state *State, cond *sync.Cond, connected *int32, dropping *int32, shutd
 	}
 }
 
+// openBrowser launches the presenter's default browser at url, unless
+// running headless ($DISPLAY unset on Linux), in which case it just logs
+// the URL. The listener may briefly not accept connections yet right after
+// binding, so dialing is retried with a short backoff first.
+func openBrowser(url, bind string) {
+	if runtime.GOOS == "linux" && os.Getenv("DISPLAY") == "" {
+		log.Printf("headless, open %s manually", url)
+		return
+	}
+
+	backoff := 20 * time.Millisecond
+	for i := 0; i < 6; i++ {
+		if conn, err := net.Dial("tcp", bind); err == nil {
+			conn.Close()
+			break
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+
+	if err := browser.OpenURL(url); err != nil {
+		log.Printf("failed to open browser: %v; open %s manually", err, url)
+	}
+}
+
 func main() {
 	state := &State{
-		Current: 1,
-		M:       &sync.RWMutex{},
+		Current:       1,
+		LegacyRefresh: legacyRefresh,
+		M:             &sync.RWMutex{},
 	}
 
-	err := state.Reload(presentation, stylesheet)
+	err := state.Reload(presentation, stylesheet, highlightName)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -531,7 +1026,10 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	sh := NewSlideHandler(ctx, state, cond)
+	connected := new(int32)
+	sh := NewSlideHandler(ctx, state, cond, connected)
+	eh := NewEventsHandler(ctx, state, cond, connected)
+	ph := NewPresenterHandler(state, time.Now())
 
 	if output != "" {
 		time.Sleep(time.Second)
@@ -548,27 +1046,54 @@ func main() {
 		return
 	}
 
+	if controlToken == "" {
+		controlToken, err = randomToken()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	ch := NewControlHandler(state, cond)
+
 	mux := http.NewServeMux()
 	mux.Handle("/", sh)
+	mux.Handle("/events", eh)
+	mux.Handle("/presenter", requireControlToken(ph))
+	mux.Handle("/control/", requireControlToken(ch))
+	mux.Handle("/remote", requireControlToken(RemoteHandler{}))
 	mux.Handle("/assets/", http.StripPrefix("/assets", http.FileServer(http.Dir(assets))))
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {})
 	mux.Handle("/favicon.ico", http.RedirectHandler(
 		"/assets/favicon.ico", http.StatusTemporaryRedirect))
 
-	srv := http.Server{Addr: bind, Handler: mux}
+	// net.Listen first (rather than leaving it to srv.ListenAndServe) so
+	// that a ":0"/"localhost:0" bind resolves to the actual assigned port
+	// before the TUI status bar, -open and the remote URL need it.
+	listener, err := net.Listen("tcp", bind)
+	if err != nil {
+		log.Fatal(err)
+	}
+	bind = listener.Addr().String()
+	log.Printf("remote control: http://%s/remote?token=%s", bind, controlToken)
+	log.Printf("presenter view: http://%s/presenter?token=%s", bind, controlToken)
+
+	srv := http.Server{Handler: mux}
 
 	dropping := int32(0)
-	go tui(state, cond, sh.connected, &dropping, cancel)
+	go tui(state, cond, connected, &dropping, cancel)
 
 	sigchan := make(chan os.Signal, 1)
 	signal.Notify(sigchan, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 
 	go func() {
-		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		if err := srv.Serve(listener); err != http.ErrServerClosed {
 			log.Fatal(err)
 		}
 	}()
 
+	if open {
+		go openBrowser("http://"+bind+"/", bind)
+	}
+
 	select {
 	case <-ctx.Done():
 	case <-sigchan: